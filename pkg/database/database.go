@@ -0,0 +1,18 @@
+package database
+
+import (
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+)
+
+// DBCommand describes a single queued database operation. It lets
+// completion handlers and dead-letter sinks inspect what was written
+// without coupling them to a specific writer implementation.
+type DBCommand interface {
+	GetReference() interface{}
+	GetRecord() *gravity_sdk_types_record.Record
+	GetQuery() (string, map[string]interface{})
+}
+
+// CompletionHandler is invoked once a DBCommand has been committed to the
+// database. Commands routed to the dead-letter sink do not trigger it.
+type CompletionHandler func(DBCommand)
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// DeadLetterSink receives commands that failed permanently so the rest of a
+// batch can proceed without them.
+type DeadLetterSink interface {
+	Send(cmd DBCommand, cause error) error
+}
+
+// DeadLetterFunc adapts a plain function to a DeadLetterSink, so a caller
+// can hand `Writer.SetErrorHandler` a closure instead of defining a type.
+type DeadLetterFunc func(cmd DBCommand, cause error) error
+
+func (fn DeadLetterFunc) Send(cmd DBCommand, cause error) error {
+	return fn(cmd, cause)
+}
+
+type deadLetterEntry struct {
+	Table string      `json:"table"`
+	Query string      `json:"query"`
+	Args  interface{} `json:"args"`
+	Cause string      `json:"cause"`
+}
+
+func newDeadLetterEntry(cmd DBCommand, cause error) deadLetterEntry {
+	query, args := cmd.GetQuery()
+
+	entry := deadLetterEntry{
+		Query: query,
+		Args:  args,
+		Cause: cause.Error(),
+	}
+
+	if record := cmd.GetRecord(); record != nil {
+		entry.Table = record.Table
+	}
+
+	return entry
+}
+
+// FileDeadLetterSink appends failed commands as JSON lines to a file, for
+// offline inspection or replay.
+type FileDeadLetterSink struct {
+	mutex sync.Mutex
+	path  string
+}
+
+func NewFileDeadLetterSink(path string) *FileDeadLetterSink {
+	return &FileDeadLetterSink{path: path}
+}
+
+func (sink *FileDeadLetterSink) Send(cmd DBCommand, cause error) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	f, err := os.OpenFile(sink.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := json.Marshal(newDeadLetterEntry(cmd, cause))
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"encoding/json"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSDeadLetterSink publishes failed commands to a NATS subject so an
+// operator-facing consumer can inspect or replay them.
+type NATSDeadLetterSink struct {
+	conn    *nats.Conn
+	subject string
+}
+
+func NewNATSDeadLetterSink(conn *nats.Conn, subject string) *NATSDeadLetterSink {
+	return &NATSDeadLetterSink{
+		conn:    conn,
+		subject: subject,
+	}
+}
+
+func (sink *NATSDeadLetterSink) Send(cmd DBCommand, cause error) error {
+	data, err := json.Marshal(newDeadLetterEntry(cmd, cause))
+	if err != nil {
+		return err
+	}
+
+	return sink.conn.Publish(sink.subject, data)
+}
@@ -0,0 +1,104 @@
+package dialect
+
+import "fmt"
+
+// UpsertMode selects how BuildUpsert resolves a primary-key conflict on
+// insert.
+type UpsertMode int
+
+const (
+	// UpsertOff disables upserting; inserts behave like plain BuildInsert.
+	UpsertOff UpsertMode = iota
+	// UpsertOnConflictUpdate overwrites the existing row's non-key columns.
+	UpsertOnConflictUpdate
+	// UpsertOnConflictNothing silently keeps the existing row.
+	UpsertOnConflictNothing
+)
+
+// ColumnBinding pairs a column name with the named parameter (sqlx
+// NamedExec binding, e.g. "val_3") its value is bound to.
+type ColumnBinding struct {
+	Column  string
+	Binding string
+}
+
+// ConnInfo holds the connection settings a Dialect needs to build a DSN.
+// It mirrors writer.DatabaseInfo so dialects don't need to import the
+// writer package.
+type ConnInfo struct {
+	Host     string
+	Port     int
+	Secure   bool
+	Username string
+	Password string
+	DbName   string
+}
+
+// Dialect isolates everything that differs between SQL backends: identifier
+// quoting, placeholder style, statement construction and how to open a
+// connection. Adding a backend means implementing this interface and
+// registering it. The writer builds statements and connections only through
+// this interface, but its COPY fast path for insert runs (pq.CopyIn) and
+// database.ClassifyError are specific to lib/pq.
+type Dialect interface {
+	Name() string
+
+	// QuoteIdent quotes a single identifier (table or column name) the way
+	// this backend expects it in a statement.
+	QuoteIdent(name string) string
+
+	// Placeholder renders the n'th (1-indexed) positional bind parameter,
+	// e.g. "$1" for Postgres or "?" for MySQL.
+	Placeholder(n int) string
+
+	// BuildInsert renders `INSERT INTO <table> (...) VALUES (...)` binding
+	// each column to its named parameter.
+	BuildInsert(table string, columns []ColumnBinding) string
+
+	// BuildUpsert renders an insert-or-update statement (e.g. Postgres
+	// `INSERT ... ON CONFLICT (primary) DO UPDATE/NOTHING`) for a record
+	// with a primary key. columns excludes primary. Only called when mode
+	// != UpsertOff and SupportsUpsert() is true.
+	BuildUpsert(table string, columns []ColumnBinding, primary ColumnBinding, mode UpsertMode) string
+
+	// BuildUpdate renders `UPDATE <table> SET ... WHERE <match[0]> = ... AND
+	// <match[1]> = ...`. match is usually the single primary key column, but
+	// may be several columns for tables matched by a composite/natural key.
+	BuildUpdate(table string, columns []ColumnBinding, match []ColumnBinding) string
+
+	// BuildDelete renders `DELETE FROM <table> WHERE <match[0]> = ... AND
+	// <match[1]> = ...`.
+	BuildDelete(table string, match []ColumnBinding) string
+
+	// OpenDSN returns the database/sql driver name and DSN to open a
+	// connection with, given ConnInfo.
+	OpenDSN(info ConnInfo) (driver string, dsn string)
+
+	// SupportsUpsert reports whether BuildInsert's backend can express
+	// INSERT-or-UPDATE in a single statement (e.g. Postgres ON CONFLICT).
+	SupportsUpsert() bool
+
+	// ColumnType infers this backend's column type declaration for a
+	// record field's decoded Go value, for CREATE TABLE / ALTER TABLE ADD
+	// COLUMN statements issued by schema auto-migration.
+	ColumnType(value interface{}) string
+}
+
+var registry = make(map[string]Dialect)
+
+// Register adds a Dialect under name, so it can be selected via the
+// `database.driver` config value. Dialect implementations call this from an
+// init() function.
+func Register(name string, d Dialect) {
+	registry[name] = d
+}
+
+// Get looks up a registered Dialect by name.
+func Get(name string) (Dialect, error) {
+	d, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("database: unknown dialect %q", name)
+	}
+
+	return d, nil
+}
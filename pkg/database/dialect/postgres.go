@@ -0,0 +1,126 @@
+package dialect
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("postgres", &postgres{})
+}
+
+// postgres is the default Dialect: double-quoted identifiers, $n
+// placeholders and a postgres:// DSN.
+type postgres struct{}
+
+func (postgres) Name() string {
+	return "postgres"
+}
+
+func (postgres) QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (postgres) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (d postgres) BuildInsert(table string, columns []ColumnBinding) string {
+	cols := make([]string, len(columns))
+	vals := make([]string, len(columns))
+	for i, col := range columns {
+		cols[i] = d.QuoteIdent(col.Column)
+		vals[i] = ":" + col.Binding
+	}
+
+	return fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`, d.QuoteIdent(table), strings.Join(cols, ","), strings.Join(vals, ","))
+}
+
+func (d postgres) BuildUpsert(table string, columns []ColumnBinding, primary ColumnBinding, mode UpsertMode) string {
+	all := append([]ColumnBinding{primary}, columns...)
+	insertStr := d.BuildInsert(table, all)
+
+	switch mode {
+	case UpsertOnConflictNothing:
+		return insertStr + fmt.Sprintf(` ON CONFLICT (%s) DO NOTHING`, d.QuoteIdent(primary.Column))
+	case UpsertOnConflictUpdate:
+		if len(columns) == 0 {
+			return insertStr + fmt.Sprintf(` ON CONFLICT (%s) DO NOTHING`, d.QuoteIdent(primary.Column))
+		}
+
+		sets := make([]string, len(columns))
+		for i, col := range columns {
+			sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", d.QuoteIdent(col.Column), d.QuoteIdent(col.Column))
+		}
+
+		return insertStr + fmt.Sprintf(` ON CONFLICT (%s) DO UPDATE SET %s`, d.QuoteIdent(primary.Column), strings.Join(sets, ","))
+	default:
+		return insertStr
+	}
+}
+
+func (d postgres) BuildUpdate(table string, columns []ColumnBinding, match []ColumnBinding) string {
+	sets := make([]string, len(columns))
+	for i, col := range columns {
+		sets[i] = d.QuoteIdent(col.Column) + " = :" + col.Binding
+	}
+
+	return fmt.Sprintf(`UPDATE %s SET %s WHERE %s`, d.QuoteIdent(table), strings.Join(sets, ","), d.buildMatchClause(match))
+}
+
+func (d postgres) BuildDelete(table string, match []ColumnBinding) string {
+	return fmt.Sprintf(`DELETE FROM %s WHERE %s`, d.QuoteIdent(table), d.buildMatchClause(match))
+}
+
+// buildMatchClause ANDs together `<column> = :<binding>` for every match
+// column, so callers can identify a row by a single primary key or by a
+// composite/natural key.
+func (d postgres) buildMatchClause(match []ColumnBinding) string {
+	conds := make([]string, len(match))
+	for i, col := range match {
+		conds[i] = d.QuoteIdent(col.Column) + " = :" + col.Binding
+	}
+
+	return strings.Join(conds, " AND ")
+}
+
+func (postgres) OpenDSN(info ConnInfo) (string, string) {
+	sslmode := "disable"
+	if info.Secure {
+		sslmode = "enable"
+	}
+
+	dsn := fmt.Sprintf(
+		"postgres://%s:%s@%s:%d/%s?sslmode=%s",
+		info.Username,
+		info.Password,
+		info.Host,
+		info.Port,
+		info.DbName,
+		sslmode,
+	)
+
+	return "postgres", dsn
+}
+
+func (postgres) SupportsUpsert() bool {
+	return true
+}
+
+func (postgres) ColumnType(value interface{}) string {
+	switch value.(type) {
+	case bool:
+		return "BOOLEAN"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "BIGINT"
+	case float32, float64:
+		return "DOUBLE PRECISION"
+	case []byte:
+		return "BYTEA"
+	case time.Time:
+		return "TIMESTAMPTZ"
+	default:
+		return "TEXT"
+	}
+}
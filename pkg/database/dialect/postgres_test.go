@@ -0,0 +1,162 @@
+package dialect
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPostgresQuoteIdent(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain identifier", "users", `"users"`},
+		{"embedded double quote is doubled", `we"ird`, `"we""ird"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (postgres{}).QuoteIdent(tt.in); got != tt.want {
+				t.Errorf("QuoteIdent(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostgresBuildInsert(t *testing.T) {
+	d := postgres{}
+	got := d.BuildInsert("users", []ColumnBinding{
+		{Column: "id", Binding: "primary_val"},
+		{Column: "name", Binding: "val_0"},
+	})
+
+	want := `INSERT INTO "users" ("id","name") VALUES (:primary_val,:val_0)`
+	if got != want {
+		t.Errorf("BuildInsert() = %s, want %s", got, want)
+	}
+}
+
+func TestPostgresBuildUpsert(t *testing.T) {
+	d := postgres{}
+	primary := ColumnBinding{Column: "id", Binding: "primary_val"}
+	columns := []ColumnBinding{{Column: "name", Binding: "val_0"}}
+
+	tests := []struct {
+		name    string
+		columns []ColumnBinding
+		mode    UpsertMode
+		want    string
+	}{
+		{
+			"off falls back to plain insert",
+			columns, UpsertOff,
+			`INSERT INTO "users" ("id","name") VALUES (:primary_val,:val_0)`,
+		},
+		{
+			"on_conflict_nothing",
+			columns, UpsertOnConflictNothing,
+			`INSERT INTO "users" ("id","name") VALUES (:primary_val,:val_0) ON CONFLICT ("id") DO NOTHING`,
+		},
+		{
+			"on_conflict_update",
+			columns, UpsertOnConflictUpdate,
+			`INSERT INTO "users" ("id","name") VALUES (:primary_val,:val_0) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`,
+		},
+		{
+			"on_conflict_update with no other columns falls back to do nothing",
+			nil, UpsertOnConflictUpdate,
+			`INSERT INTO "users" ("id") VALUES (:primary_val) ON CONFLICT ("id") DO NOTHING`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.BuildUpsert("users", tt.columns, primary, tt.mode); got != tt.want {
+				t.Errorf("BuildUpsert() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostgresBuildUpdate(t *testing.T) {
+	d := postgres{}
+
+	t.Run("single primary key match", func(t *testing.T) {
+		got := d.BuildUpdate("users",
+			[]ColumnBinding{{Column: "name", Binding: "val_0"}},
+			[]ColumnBinding{{Column: "id", Binding: "primary_val"}},
+		)
+
+		want := `UPDATE "users" SET "name" = :val_0 WHERE "id" = :primary_val`
+		if got != want {
+			t.Errorf("BuildUpdate() = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("composite match columns are ANDed", func(t *testing.T) {
+		got := d.BuildUpdate("orders",
+			[]ColumnBinding{{Column: "status", Binding: "val_0"}},
+			[]ColumnBinding{
+				{Column: "tenant_id", Binding: "match_0"},
+				{Column: "order_no", Binding: "match_1"},
+			},
+		)
+
+		want := `UPDATE "orders" SET "status" = :val_0 WHERE "tenant_id" = :match_0 AND "order_no" = :match_1`
+		if got != want {
+			t.Errorf("BuildUpdate() = %s, want %s", got, want)
+		}
+	})
+}
+
+func TestPostgresBuildDelete(t *testing.T) {
+	d := postgres{}
+
+	t.Run("single primary key match", func(t *testing.T) {
+		got := d.BuildDelete("users", []ColumnBinding{{Column: "id", Binding: "primary_val"}})
+
+		want := `DELETE FROM "users" WHERE "id" = :primary_val`
+		if got != want {
+			t.Errorf("BuildDelete() = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("composite match columns are ANDed", func(t *testing.T) {
+		got := d.BuildDelete("orders", []ColumnBinding{
+			{Column: "tenant_id", Binding: "match_0"},
+			{Column: "order_no", Binding: "match_1"},
+		})
+
+		want := `DELETE FROM "orders" WHERE "tenant_id" = :match_0 AND "order_no" = :match_1`
+		if got != want {
+			t.Errorf("BuildDelete() = %s, want %s", got, want)
+		}
+	})
+}
+
+func TestPostgresColumnType(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"bool", true, "BOOLEAN"},
+		{"int", int(1), "BIGINT"},
+		{"int64", int64(1), "BIGINT"},
+		{"uint32", uint32(1), "BIGINT"},
+		{"float64", float64(1.5), "DOUBLE PRECISION"},
+		{"bytes", []byte("x"), "BYTEA"},
+		{"time", time.Now(), "TIMESTAMPTZ"},
+		{"string falls back to text", "x", "TEXT"},
+		{"nil falls back to text", nil, "TEXT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (postgres{}).ColumnType(tt.value); got != tt.want {
+				t.Errorf("ColumnType(%#v) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
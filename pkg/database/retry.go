@@ -0,0 +1,106 @@
+package database
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrorClass classifies a database error as worth retrying or not.
+type ErrorClass int
+
+const (
+	// ErrorClassTransient covers connection loss, serialization failures and
+	// deadlocks: retrying the same statement is expected to eventually
+	// succeed.
+	ErrorClassTransient ErrorClass = iota
+	// ErrorClassPermanent covers constraint violations, bad syntax and
+	// similar errors that will fail identically on every retry.
+	ErrorClassPermanent
+)
+
+// permanentSQLStates are Postgres error codes that won't resolve by retrying
+// the exact same statement. See https://www.postgresql.org/docs/current/errcodes-appendix.html
+var permanentSQLStates = map[string]bool{
+	"23502": true, // not_null_violation
+	"23503": true, // foreign_key_violation
+	"23505": true, // unique_violation
+	"23514": true, // check_violation
+	"42601": true, // syntax_error
+	"42703": true, // undefined_column
+	"42P01": true, // undefined_table
+}
+
+// transientSQLStates are Postgres error codes worth retrying.
+var transientSQLStates = map[string]bool{
+	"08000": true, // connection_exception
+	"08003": true, // connection_does_not_exist
+	"08006": true, // connection_failure
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"57P03": true, // cannot_connect_now
+}
+
+// ClassifyError decides whether err is worth retrying. Errors that aren't
+// recognized pq errors at all (e.g. a dropped connection surfaced by the
+// driver as a plain net error) default to transient, since misclassifying a
+// network blip as permanent would silently drop good data.
+func ClassifyError(err error) ErrorClass {
+	if pqErr, ok := err.(*pq.Error); ok {
+		if permanentSQLStates[string(pqErr.Code)] {
+			return ErrorClassPermanent
+		}
+		if transientSQLStates[string(pqErr.Code)] {
+			return ErrorClassTransient
+		}
+
+		return ErrorClassPermanent
+	}
+
+	return ErrorClassTransient
+}
+
+// RetryPolicy bounds how many times, and how long, the writer waits before
+// giving up on a transient failure and routing it to the dead-letter sink.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// NewRetryPolicy builds a RetryPolicy, falling back to sane defaults (10
+// attempts, starting at 500ms and capping at 30s) for any zero value.
+func NewRetryPolicy(maxAttempts int, baseDelay, maxDelay time.Duration) *RetryPolicy {
+	if maxAttempts <= 0 {
+		maxAttempts = 10
+	}
+	if baseDelay <= 0 {
+		baseDelay = 500 * time.Millisecond
+	}
+	if maxDelay <= 0 {
+		maxDelay = 30 * time.Second
+	}
+
+	return &RetryPolicy{
+		MaxAttempts: maxAttempts,
+		BaseDelay:   baseDelay,
+		MaxDelay:    maxDelay,
+	}
+}
+
+// Backoff returns how long to wait before a retry, using exponential
+// backoff with full jitter so a herd of failing commands doesn't retry in
+// lockstep.
+func (policy *RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := policy.BaseDelay << uint(attempt-1)
+	if delay <= 0 || delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+
+	return time.Duration(rand.Int63n(int64(delay)))
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{"unique_violation", &pq.Error{Code: "23505"}, ErrorClassPermanent},
+		{"not_null_violation", &pq.Error{Code: "23502"}, ErrorClassPermanent},
+		{"syntax_error", &pq.Error{Code: "42601"}, ErrorClassPermanent},
+		{"connection_failure", &pq.Error{Code: "08006"}, ErrorClassTransient},
+		{"deadlock_detected", &pq.Error{Code: "40P01"}, ErrorClassTransient},
+		{"unrecognized pq code defaults permanent", &pq.Error{Code: "99999"}, ErrorClassPermanent},
+		{"non-pq error defaults transient", errors.New("connection reset by peer"), ErrorClassTransient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := NewRetryPolicy(10, 500*time.Millisecond, 30*time.Second)
+
+	t.Run("stays within max delay", func(t *testing.T) {
+		for attempt := 1; attempt <= 20; attempt++ {
+			delay := policy.Backoff(attempt)
+			if delay < 0 || delay > policy.MaxDelay {
+				t.Fatalf("Backoff(%d) = %v, want within [0, %v]", attempt, delay, policy.MaxDelay)
+			}
+		}
+	})
+
+	t.Run("treats attempts below 1 as attempt 1", func(t *testing.T) {
+		for attempt := -1; attempt <= 0; attempt++ {
+			if delay := policy.Backoff(attempt); delay < 0 || delay > policy.BaseDelay {
+				t.Errorf("Backoff(%d) = %v, want within [0, %v]", attempt, delay, policy.BaseDelay)
+			}
+		}
+	})
+
+	t.Run("large attempt counts don't overflow into a negative delay", func(t *testing.T) {
+		if delay := policy.Backoff(64); delay < 0 || delay > policy.MaxDelay {
+			t.Errorf("Backoff(64) = %v, want within [0, %v]", delay, policy.MaxDelay)
+		}
+	})
+}
@@ -4,23 +4,78 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
-	"strings"
 	"time"
 
 	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
 	"github.com/BrobridgeOrg/gravity-transmitter-postgres/pkg/database"
+	"github.com/BrobridgeOrg/gravity-transmitter-postgres/pkg/database/dialect"
+	"github.com/BrobridgeOrg/gravity-transmitter-postgres/pkg/schema"
 	buffered_input "github.com/cfsghost/buffered-input"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
-var (
-	UpdateTemplate = `UPDATE "%s" SET %s WHERE "%s" = :primary_val`
-	InsertTemplate = `INSERT INTO "%s" (%s) VALUES (%s)`
-	DeleteTemplate = `DELETE FROM "%s" WHERE "%s" = :primary_val`
-)
+// DefaultDriver is the dialect used when `database.driver` isn't set.
+const DefaultDriver = "postgres"
+
+// DefaultCopyThreshold is the number of contiguous, homogeneous INSERT
+// commands required in a chunk before the writer switches from per-row
+// NamedExec calls to the bulk pq.CopyIn protocol.
+const DefaultCopyThreshold = 50
+
+// parseUpsertMode maps the `database.upsert` config value to a
+// dialect.UpsertMode, defaulting to UpsertOff for an empty or unrecognized
+// value.
+func parseUpsertMode(mode string) dialect.UpsertMode {
+	switch mode {
+	case "on_conflict_update":
+		return dialect.UpsertOnConflictUpdate
+	case "on_conflict_nothing":
+		return dialect.UpsertOnConflictNothing
+	default:
+		return dialect.UpsertOff
+	}
+}
+
+// DBCommand is a single queued database operation. For INSERT commands,
+// Table/Columns/Values are also populated (in column order) so that runs of
+// homogeneous inserts can be pushed through the COPY fast-path instead of
+// the named-exec path.
+type DBCommand struct {
+	Reference interface{}
+	Record    *gravity_sdk_types_record.Record
+	QueryStr  string
+	Args      map[string]interface{}
+
+	// IsInsert marks a plain (non-upsert) INSERT as eligible for the COPY
+	// fast-path; upserts always go through NamedExec since COPY can't
+	// express ON CONFLICT.
+	IsInsert bool
+	Table    string
+	Columns  []string
+	Values   []interface{}
+
+	// NonIdempotent marks an INSERT with no primary key: since it can't be
+	// de-duplicated, it must never be blindly retried after a commit whose
+	// outcome is unknown.
+	NonIdempotent bool
+
+	attempts int
+}
+
+func (cmd *DBCommand) GetReference() interface{} {
+	return cmd.Reference
+}
+
+func (cmd *DBCommand) GetRecord() *gravity_sdk_types_record.Record {
+	return cmd.Record
+}
+
+func (cmd *DBCommand) GetQuery() (string, map[string]interface{}) {
+	return cmd.QueryStr, cmd.Args
+}
 
 type DatabaseInfo struct {
 	Host     string `json:"host"`
@@ -39,6 +94,12 @@ type Writer struct {
 	buffer            *buffered_input.BufferedInput
 	tmpQueryStr       string
 	handleQueryStr    string
+	copyThreshold     int
+	retryPolicy       *database.RetryPolicy
+	errorHandler      database.DeadLetterSink
+	dialect           dialect.Dialect
+	upsertMode        dialect.UpsertMode
+	schema            *schema.Resolver
 }
 
 func NewWriter() *Writer {
@@ -48,6 +109,7 @@ func NewWriter() *Writer {
 		completionHandler: func(database.DBCommand) {},
 		tmpQueryStr:       "",
 		handleQueryStr:    "",
+		retryPolicy:       database.NewRetryPolicy(0, 0, 0),
 	}
 
 	// Initializing buffered input
@@ -71,7 +133,32 @@ func (writer *Writer) Init() error {
 	writer.dbInfo.Password = viper.GetString("database.password")
 	writer.dbInfo.DbName = viper.GetString("database.dbname")
 
+	writer.copyThreshold = viper.GetInt("database.copy_threshold")
+	if writer.copyThreshold <= 0 {
+		writer.copyThreshold = DefaultCopyThreshold
+	}
+
+	writer.retryPolicy = database.NewRetryPolicy(
+		viper.GetInt("database.retry.max_attempts"),
+		viper.GetDuration("database.retry.base_delay"),
+		viper.GetDuration("database.retry.max_delay"),
+	)
+
+	driverName := viper.GetString("database.driver")
+	if driverName == "" {
+		driverName = DefaultDriver
+	}
+
+	d, err := dialect.Get(driverName)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	writer.dialect = d
+	writer.upsertMode = parseUpsertMode(viper.GetString("database.upsert"))
+
 	log.WithFields(log.Fields{
+		"driver":   driverName,
 		"host":     writer.dbInfo.Host,
 		"port":     writer.dbInfo.Port,
 		"secure":   writer.dbInfo.Secure,
@@ -79,23 +166,17 @@ func (writer *Writer) Init() error {
 		"dbname":   writer.dbInfo.DbName,
 	}).Info("Connecting to database")
 
-	sslmode := "disable"
-	if writer.dbInfo.Secure {
-		sslmode = "enable"
-	}
-
-	connStr := fmt.Sprintf(
-		"postgres://%s:%s@%s:%d/%s?sslmode=%s",
-		writer.dbInfo.Username,
-		writer.dbInfo.Password,
-		writer.dbInfo.Host,
-		writer.dbInfo.Port,
-		writer.dbInfo.DbName,
-		sslmode,
-	)
+	sqlDriver, connStr := writer.dialect.OpenDSN(dialect.ConnInfo{
+		Host:     writer.dbInfo.Host,
+		Port:     writer.dbInfo.Port,
+		Secure:   writer.dbInfo.Secure,
+		Username: writer.dbInfo.Username,
+		Password: writer.dbInfo.Password,
+		DbName:   writer.dbInfo.DbName,
+	})
 
 	// Open database
-	db, err := sqlx.Open("postgres", connStr)
+	db, err := sqlx.Open(sqlDriver, connStr)
 	if err != nil {
 		log.Error(err)
 		return err
@@ -105,6 +186,7 @@ func (writer *Writer) Init() error {
 	db.SetMaxIdleConns(10)
 
 	writer.db = db
+	writer.schema = schema.NewResolver(db, writer.dialect, viper.GetBool("database.auto_migrate"))
 
 	go writer.run()
 
@@ -122,53 +204,266 @@ func (writer *Writer) chunkHandler(chunk []interface{}) {
 }
 
 func (writer *Writer) processData(dbCommands []*DBCommand) {
-	// Write to Database
-	for {
-	LOOP:
+	pending := dbCommands
+	commitAttempts := 0
 
+	// Write to Database
+	for len(pending) > 0 {
 		tx, err := writer.db.Beginx()
 		if err != nil {
 			log.Error(err)
+			<-time.After(writer.retryPolicy.Backoff(1))
+			continue
+		}
+
+		failedCmd, execErr := writer.execBatch(tx, pending)
+		if execErr != nil {
 			tx.Rollback()
+			pending = writer.handleExecFailure(pending, failedCmd, execErr)
+			continue
+		}
 
-			<-time.After(time.Second * 5)
+		if err := tx.Commit(); err != nil {
+			tx.Rollback()
 
-			log.WithFields(log.Fields{}).Warn("Retry to write record to database by batch ...")
+			commitAttempts++
+			pending = writer.handleCommitFailure(pending, err, commitAttempts)
 			continue
 		}
 
-		for _, cmd := range dbCommands {
-			_, err := tx.NamedExec(cmd.QueryStr, cmd.Args)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"pkey_field": cmd.Record.PrimaryKey,
-				}).Error(err)
-				log.Error(cmd.QueryStr)
-				log.Error(cmd.Args)
-				tx.Rollback()
-				<-time.After(time.Second * 5)
-				goto LOOP
+		break
+	}
+
+	// pending, at this point, holds exactly the commands that made it into
+	// the transaction that was actually committed: anything dead-lettered
+	// along the way was already removed from it by handleExecFailure /
+	// handleCommitFailure. Firing completion for those too would tell the
+	// rest of the pipeline a permanently-dropped record was written.
+	for _, cmd := range pending {
+		writer.completionHandler(database.DBCommand(cmd))
+	}
+}
 
+// execBatch runs dbCommands against tx, taking the COPY fast-path for
+// eligible runs of inserts and NamedExec otherwise. It stops and returns the
+// offending command on the first failure.
+func (writer *Writer) execBatch(tx *sqlx.Tx, dbCommands []*DBCommand) (*DBCommand, error) {
+	for i := 0; i < len(dbCommands); {
+		if run := writer.copyableRun(dbCommands[i:]); len(run) >= writer.copyThreshold {
+			if err := writer.copyInsert(tx, dbCommands[i].Table, dbCommands[i].Columns, run); err != nil {
+				failedCmd, isolateErr := writer.isolateFailingCommand(run)
+				if failedCmd != nil {
+					return failedCmd, isolateErr
+				}
+
+				// Couldn't reproduce the failure against any single row in
+				// isolation; blame the run as a whole via its first command
+				// rather than guessing.
+				log.WithFields(log.Fields{"table": dbCommands[i].Table}).Warn("Could not isolate failing row in COPY batch: ", err)
+				return dbCommands[i], err
 			}
+
+			i += len(run)
+			continue
 		}
-		err = tx.Commit()
 
-		if err != nil {
-			log.Error(err)
-			tx.Rollback()
+		cmd := dbCommands[i]
+		if _, err := tx.NamedExec(cmd.QueryStr, cmd.Args); err != nil {
+			return cmd, err
+		}
 
-			<-time.After(time.Second * 5)
+		i++
+	}
 
-			log.WithFields(log.Fields{}).Warn("Retry to write record to database by batch ...")
-			continue
+	return nil, nil
+}
+
+// isolateFailingCommand finds which command in run actually caused a COPY
+// failure: Postgres's COPY protocol reports one error for the whole batch,
+// not a row index, so blaming run[0] would dead-letter an arbitrary command
+// instead of the one that's actually bad. It re-runs run one statement at a
+// time via NamedExec in a throwaway transaction, which is always rolled
+// back regardless of outcome - this is purely a diagnostic probe, and the
+// commands that succeed here are left in pending to be retried normally
+// once the real culprit has been dead-lettered.
+func (writer *Writer) isolateFailingCommand(run []*DBCommand) (*DBCommand, error) {
+	tx, err := writer.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, cmd := range run {
+		if _, err := tx.NamedExec(cmd.QueryStr, cmd.Args); err != nil {
+			return cmd, err
 		}
+	}
 
-		break
+	return nil, nil
+}
+
+// handleExecFailure classifies a single statement's failure: permanent
+// errors (and transient ones that have exhausted the retry policy) are
+// dead-lettered and dropped from the batch; anything else waits out a
+// backoff and is retried along with the rest of pending.
+func (writer *Writer) handleExecFailure(pending []*DBCommand, cmd *DBCommand, err error) []*DBCommand {
+	log.WithFields(log.Fields{
+		"table":      cmd.Table,
+		"pkey_field": cmd.Record.PrimaryKey,
+	}).Error(err)
+	log.Error(cmd.QueryStr)
+	log.Error(cmd.Args)
+
+	cmd.attempts++
+
+	if database.ClassifyError(err) == database.ErrorClassPermanent || cmd.attempts >= writer.retryPolicy.MaxAttempts {
+		writer.deadLetter(cmd, err)
+		return removeCommand(pending, cmd)
 	}
 
-	for _, cmd := range dbCommands {
-		writer.completionHandler(database.DBCommand(cmd))
+	log.WithFields(log.Fields{"attempt": cmd.attempts}).Warn("Retry to write record to database by batch ...")
+	<-time.After(writer.retryPolicy.Backoff(cmd.attempts))
+
+	return pending
+}
+
+// handleCommitFailure deals with a failed tx.Commit, whose outcome on the
+// server is unknown. INSERTs with no primary key can't be de-duplicated if
+// they actually landed, so they're never retried blindly - they're
+// dead-lettered immediately. Everything else is retried, up to the policy's
+// attempt cap, after which the whole remaining batch is dead-lettered. A
+// commit error classified as permanent dead-letters the whole batch at once.
+func (writer *Writer) handleCommitFailure(pending []*DBCommand, err error, attempt int) []*DBCommand {
+	log.Error(err)
+
+	unsafe := make([]*DBCommand, 0, len(pending))
+	for _, cmd := range pending {
+		if cmd.NonIdempotent {
+			unsafe = append(unsafe, cmd)
+		}
+	}
+
+	if database.ClassifyError(err) == database.ErrorClassPermanent || attempt >= writer.retryPolicy.MaxAttempts {
+		unsafe = pending
+	}
+
+	for _, cmd := range unsafe {
+		writer.deadLetter(cmd, err)
 	}
+
+	remaining := pending
+	if len(unsafe) > 0 {
+		remaining = removeCommands(pending, unsafe)
+	} else {
+		log.WithFields(log.Fields{}).Warn("Retry to write record to database by batch ...")
+	}
+
+	// Back off here regardless of whether anything was dead-lettered above,
+	// same as every other retry path - otherwise a batch with a NonIdempotent
+	// command hammers the database with zero delay on every commit failure.
+	<-time.After(writer.retryPolicy.Backoff(attempt))
+
+	return remaining
+}
+
+// deadLetter routes cmd to the configured error handler, if any, so a
+// poison record can't block the rest of the batch forever.
+func (writer *Writer) deadLetter(cmd *DBCommand, cause error) {
+	log.WithFields(log.Fields{"table": cmd.Table}).Warn("Dead-lettering command: ", cause)
+
+	if writer.errorHandler == nil {
+		return
+	}
+
+	if err := writer.errorHandler.Send(cmd, cause); err != nil {
+		log.Error(err)
+	}
+}
+
+func removeCommand(pending []*DBCommand, target *DBCommand) []*DBCommand {
+	out := make([]*DBCommand, 0, len(pending))
+	for _, cmd := range pending {
+		if cmd != target {
+			out = append(out, cmd)
+		}
+	}
+
+	return out
+}
+
+func removeCommands(pending []*DBCommand, targets []*DBCommand) []*DBCommand {
+	skip := make(map[*DBCommand]bool, len(targets))
+	for _, cmd := range targets {
+		skip[cmd] = true
+	}
+
+	out := make([]*DBCommand, 0, len(pending))
+	for _, cmd := range pending {
+		if !skip[cmd] {
+			out = append(out, cmd)
+		}
+	}
+
+	return out
+}
+
+// copyableRun returns the longest leading run of dbCommands that are all
+// INSERTs into the same table with the same column set, so they can be
+// loaded together through a single COPY statement.
+func (writer *Writer) copyableRun(dbCommands []*DBCommand) []*DBCommand {
+	if len(dbCommands) == 0 || !dbCommands[0].IsInsert {
+		return nil
+	}
+
+	head := dbCommands[0]
+	run := dbCommands[:1]
+	for _, cmd := range dbCommands[1:] {
+		if !cmd.IsInsert || cmd.Table != head.Table || !sameColumns(cmd.Columns, head.Columns) {
+			break
+		}
+
+		run = dbCommands[:len(run)+1]
+	}
+
+	return run
+}
+
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// copyInsert bulk-loads a run of same-shape INSERT commands into table using
+// the COPY protocol: a prepared statement is fed one row per command, then
+// flushed with a final empty Exec before being closed.
+func (writer *Writer) copyInsert(tx *sqlx.Tx, table string, columns []string, run []*DBCommand) error {
+	stmt, err := tx.Prepare(pq.CopyIn(table, columns...))
+	if err != nil {
+		return err
+	}
+
+	for _, cmd := range run {
+		if _, err := stmt.Exec(cmd.Values...); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+
+	return stmt.Close()
 }
 
 func (writer *Writer) run() {
@@ -185,6 +480,13 @@ func (writer *Writer) SetCompletionHandler(fn database.CompletionHandler) {
 	writer.completionHandler = fn
 }
 
+// SetErrorHandler registers the sink that permanently-failed commands are
+// routed to. Use database.DeadLetterFunc to register a plain callback, or
+// one of database.NewFileDeadLetterSink / database.NewNATSDeadLetterSink.
+func (writer *Writer) SetErrorHandler(sink database.DeadLetterSink) {
+	writer.errorHandler = sink
+}
+
 func (writer *Writer) ProcessData(reference interface{}, record *gravity_sdk_types_record.Record) error {
 	/*
 		log.WithFields(log.Fields{
@@ -267,8 +569,8 @@ func (writer *Writer) UpdateRecord(reference interface{}, record *gravity_sdk_ty
 		return err
 	}
 
-	// Ignore if no primary key
-	if recordDef.HasPrimary == false {
+	// Ignore if there's nothing to match this row by
+	if !recordDef.HasPrimary && len(writer.matchColumns(record.Table)) == 0 {
 		return nil
 	}
 
@@ -282,53 +584,130 @@ func (writer *Writer) UpdateRecord(reference interface{}, record *gravity_sdk_ty
 
 func (writer *Writer) DeleteRecord(reference interface{}, record *gravity_sdk_types_record.Record) error {
 
-	if record.PrimaryKey == "" {
-		// Do nothing
+	args := make(map[string]interface{})
+
+	match, err := writer.matchBindings(record.Table, record, args)
+	if err != nil {
+		// No primary key and no match_columns configured, or a match
+		// column is missing from the record: nothing to target for
+		// deletion.
 		return nil
 	}
 
-	for _, field := range record.Fields {
+	sqlStr := writer.dialect.BuildDelete(record.Table, match)
 
-		// Primary key
-		//		if field.IsPrimary == true {
-		if record.PrimaryKey == field.Name {
+	writer.commands <- &DBCommand{
+		Reference: reference,
+		Record:    record,
+		QueryStr:  sqlStr,
+		Args:      args,
+	}
 
-			value := gravity_sdk_types_record.GetValue(field.Value)
+	return nil
+}
 
-			sqlStr := fmt.Sprintf(DeleteTemplate, record.Table, field.Name)
+// matchColumns returns table's configured `database.tables.<table>.match_columns`,
+// the natural-key columns to identify a row by instead of record.PrimaryKey.
+func (writer *Writer) matchColumns(table string) []string {
+	return viper.GetStringSlice(fmt.Sprintf("database.tables.%s.match_columns", table))
+}
 
-			writer.commands <- &DBCommand{
-				Reference: reference,
-				Record:    record,
-				QueryStr:  sqlStr,
-				Args: map[string]interface{}{
-					"primary_val": value,
-				},
-			}
+// matchBindings resolves the WHERE-clause columns for table: its configured
+// match_columns if any, otherwise record.PrimaryKey. Each column's value is
+// read off record and added to args under a fresh binding name.
+func (writer *Writer) matchBindings(table string, record *gravity_sdk_types_record.Record, args map[string]interface{}) ([]dialect.ColumnBinding, error) {
+	names := writer.matchColumns(table)
+	if len(names) == 0 {
+		if record.PrimaryKey == "" {
+			return nil, errors.New("no primary key or match_columns configured")
+		}
 
-			break
+		names = []string{record.PrimaryKey}
+	}
+
+	bindings := make([]dialect.ColumnBinding, 0, len(names))
+	for i, name := range names {
+		value, ok := fieldValue(record, name)
+		if !ok {
+			return nil, fmt.Errorf("match column %q not found in record", name)
 		}
+
+		binding := fmt.Sprintf("match_%d", i)
+		args[binding] = value
+		bindings = append(bindings, dialect.ColumnBinding{Column: name, Binding: binding})
 	}
 
-	return nil
+	return bindings, nil
+}
+
+func fieldValue(record *gravity_sdk_types_record.Record, name string) (interface{}, bool) {
+	for _, field := range record.Fields {
+		if field.Name == name {
+			return gravity_sdk_types_record.GetValue(field.Value), true
+		}
+	}
+
+	return nil, false
+}
+
+// schemaColumns turns a RecordDef into the column set the schema resolver
+// needs to check a table for drift.
+func schemaColumns(recordDef *gravity_sdk_types_record.RecordDef) []schema.Column {
+	columns := make([]schema.Column, 0, len(recordDef.ColumnDefs)+1)
+	if recordDef.HasPrimary {
+		columns = append(columns, schema.Column{
+			Name:      recordDef.PrimaryColumn,
+			Value:     recordDef.Values["primary_val"],
+			IsPrimary: true,
+		})
+	}
+
+	for _, def := range recordDef.ColumnDefs {
+		columns = append(columns, schema.Column{
+			Name:  def.ColumnName,
+			Value: recordDef.Values[def.BindingName],
+		})
+	}
+
+	return columns
 }
 
 func (writer *Writer) update(reference interface{}, record *gravity_sdk_types_record.Record, table string, recordDef *gravity_sdk_types_record.RecordDef) (bool, error) {
 
-	// Preparing SQL string
-	updates := make([]string, 0, len(recordDef.ColumnDefs))
+	if err := writer.schema.Ensure(table, schemaColumns(recordDef)); err != nil {
+		return false, err
+	}
+
+	args := recordDef.Values
+
+	match, err := writer.matchBindings(table, record, args)
+	if err != nil {
+		return false, err
+	}
+
+	matched := make(map[string]bool, len(match))
+	for _, col := range match {
+		matched[col.Column] = true
+	}
+
+	// Preparing columns and bindings, excluding whatever the row is matched
+	// by (it shouldn't also be overwritten in SET).
+	columns := make([]dialect.ColumnBinding, 0, len(recordDef.ColumnDefs))
 	for _, def := range recordDef.ColumnDefs {
-		updates = append(updates, `"`+def.ColumnName+`" = :`+def.BindingName)
+		if matched[def.ColumnName] {
+			continue
+		}
+
+		columns = append(columns, dialect.ColumnBinding{Column: def.ColumnName, Binding: def.BindingName})
 	}
 
-	updateStr := strings.Join(updates, ",")
-	sqlStr := fmt.Sprintf(UpdateTemplate, table, updateStr, recordDef.PrimaryColumn)
+	sqlStr := writer.dialect.BuildUpdate(table, columns, match)
 
 	writer.commands <- &DBCommand{
 		Reference: reference,
 		Record:    record,
 		QueryStr:  sqlStr,
-		Args:      recordDef.Values,
+		Args:      args,
 	}
 
 	return false, nil
@@ -336,37 +715,56 @@ func (writer *Writer) update(reference interface{}, record *gravity_sdk_types_re
 
 func (writer *Writer) insert(reference interface{}, record *gravity_sdk_types_record.Record, table string, recordDef *gravity_sdk_types_record.RecordDef) error {
 
+	if err := writer.schema.Ensure(table, schemaColumns(recordDef)); err != nil {
+		return err
+	}
+
 	paramLength := len(recordDef.ColumnDefs)
 	if recordDef.HasPrimary {
 		paramLength++
 	}
 
-	// Allocation
-	colNames := make([]string, 0, paramLength)
-	valNames := make([]string, 0, paramLength)
+	// Preparing columns and bindings
+	nonPrimary := make([]dialect.ColumnBinding, 0, len(recordDef.ColumnDefs))
+	for _, def := range recordDef.ColumnDefs {
+		nonPrimary = append(nonPrimary, dialect.ColumnBinding{Column: def.ColumnName, Binding: def.BindingName})
+	}
 
+	bindings := make([]dialect.ColumnBinding, 0, paramLength)
 	if recordDef.HasPrimary {
-		colNames = append(colNames, `"`+recordDef.PrimaryColumn+`"`)
-		valNames = append(valNames, ":primary_val")
+		bindings = append(bindings, dialect.ColumnBinding{Column: recordDef.PrimaryColumn, Binding: "primary_val"})
 	}
-
-	// Preparing columns and bindings
-	for _, def := range recordDef.ColumnDefs {
-		colNames = append(colNames, `"`+def.ColumnName+`"`)
-		valNames = append(valNames, `:`+def.BindingName)
+	bindings = append(bindings, nonPrimary...)
+
+	var insertStr string
+	usesUpsert := recordDef.HasPrimary && writer.upsertMode != dialect.UpsertOff && writer.dialect.SupportsUpsert()
+	if usesUpsert {
+		primary := dialect.ColumnBinding{Column: recordDef.PrimaryColumn, Binding: "primary_val"}
+		insertStr = writer.dialect.BuildUpsert(table, nonPrimary, primary, writer.upsertMode)
+	} else {
+		insertStr = writer.dialect.BuildInsert(table, bindings)
 	}
 
-	// Preparing SQL string to insert
-	colsStr := strings.Join(colNames, ",")
-	valsStr := strings.Join(valNames, ",")
-	insertStr := fmt.Sprintf(InsertTemplate, table, colsStr, valsStr)
+	// Plain column names and their values in the same order, used by the
+	// COPY fast-path when this insert can be batched with others.
+	columns := make([]string, 0, paramLength)
+	values := make([]interface{}, 0, paramLength)
+	for _, binding := range bindings {
+		columns = append(columns, binding.Column)
+		values = append(values, recordDef.Values[binding.Binding])
+	}
 
 	//	database.db.NamedExec(insertStr, recordDef.Values)
 	writer.commands <- &DBCommand{
-		Reference: reference,
-		Record:    record,
-		QueryStr:  insertStr,
-		Args:      recordDef.Values,
+		Reference:     reference,
+		Record:        record,
+		QueryStr:      insertStr,
+		Args:          recordDef.Values,
+		IsInsert:      !usesUpsert,
+		NonIdempotent: !recordDef.HasPrimary,
+		Table:         table,
+		Columns:       columns,
+		Values:        values,
 	}
 
 	return nil
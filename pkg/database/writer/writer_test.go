@@ -0,0 +1,96 @@
+package writer
+
+import "testing"
+
+func TestSameColumns(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want bool
+	}{
+		{"identical", []string{"id", "name"}, []string{"id", "name"}, true},
+		{"different order", []string{"id", "name"}, []string{"name", "id"}, false},
+		{"different length", []string{"id", "name"}, []string{"id"}, false},
+		{"both empty", nil, []string{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameColumns(tt.a, tt.b); got != tt.want {
+				t.Errorf("sameColumns(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func insertCmd(table string, columns ...string) *DBCommand {
+	return &DBCommand{IsInsert: true, Table: table, Columns: columns}
+}
+
+func TestCopyableRun(t *testing.T) {
+	writer := NewWriter()
+
+	t.Run("stops at a non-insert", func(t *testing.T) {
+		cmds := []*DBCommand{
+			insertCmd("users", "id", "name"),
+			insertCmd("users", "id", "name"),
+			{IsInsert: false, Table: "users"},
+			insertCmd("users", "id", "name"),
+		}
+
+		run := writer.copyableRun(cmds)
+		if len(run) != 2 {
+			t.Fatalf("len(run) = %d, want 2", len(run))
+		}
+	})
+
+	t.Run("stops at a different table", func(t *testing.T) {
+		cmds := []*DBCommand{
+			insertCmd("users", "id"),
+			insertCmd("orders", "id"),
+		}
+
+		run := writer.copyableRun(cmds)
+		if len(run) != 1 {
+			t.Fatalf("len(run) = %d, want 1", len(run))
+		}
+	})
+
+	t.Run("stops at a different column set", func(t *testing.T) {
+		cmds := []*DBCommand{
+			insertCmd("users", "id", "name"),
+			insertCmd("users", "id", "email"),
+		}
+
+		run := writer.copyableRun(cmds)
+		if len(run) != 1 {
+			t.Fatalf("len(run) = %d, want 1", len(run))
+		}
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		if run := writer.copyableRun(nil); run != nil {
+			t.Fatalf("copyableRun(nil) = %v, want nil", run)
+		}
+	})
+
+	t.Run("leading command is not an insert", func(t *testing.T) {
+		cmds := []*DBCommand{{IsInsert: false}}
+		if run := writer.copyableRun(cmds); run != nil {
+			t.Fatalf("copyableRun(%v) = %v, want nil", cmds, run)
+		}
+	})
+
+	t.Run("whole run is homogeneous", func(t *testing.T) {
+		cmds := []*DBCommand{
+			insertCmd("users", "id"),
+			insertCmd("users", "id"),
+			insertCmd("users", "id"),
+		}
+
+		run := writer.copyableRun(cmds)
+		if len(run) != len(cmds) {
+			t.Fatalf("len(run) = %d, want %d", len(run), len(cmds))
+		}
+	})
+}
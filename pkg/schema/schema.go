@@ -0,0 +1,220 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/BrobridgeOrg/gravity-transmitter-postgres/pkg/database/dialect"
+	"github.com/jmoiron/sqlx"
+	log "github.com/sirupsen/logrus"
+)
+
+// Column describes one field of a record, enough to infer a column
+// definition for CREATE TABLE / ALTER TABLE ADD COLUMN.
+type Column struct {
+	Name      string
+	Value     interface{}
+	IsPrimary bool
+}
+
+// tableSchema is what Resolver knows about a single table's columns.
+type tableSchema struct {
+	columns map[string]bool
+}
+
+func (s *tableSchema) hasAll(columns []Column) bool {
+	for _, col := range columns {
+		if !s.columns[col.Name] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Resolver tracks each table's known columns, querying
+// information_schema.columns on first sight of a (table, columnSet) and,
+// when autoMigrate is enabled, issuing CREATE TABLE / ALTER TABLE ADD
+// COLUMN statements inferred from the record's field values. All SQL it
+// emits goes through dialect so it isn't tied to a single backend.
+type Resolver struct {
+	db          *sqlx.DB
+	dialect     dialect.Dialect
+	autoMigrate bool
+
+	mutex      sync.RWMutex
+	tables     map[string]*tableSchema
+	tableLocks map[string]*sync.Mutex
+}
+
+func NewResolver(db *sqlx.DB, d dialect.Dialect, autoMigrate bool) *Resolver {
+	return &Resolver{
+		db:          db,
+		dialect:     d,
+		autoMigrate: autoMigrate,
+		tables:      make(map[string]*tableSchema),
+		tableLocks:  make(map[string]*sync.Mutex),
+	}
+}
+
+// lockTable returns the mutex that serializes Ensure for table, creating it
+// on first use.
+func (r *Resolver) lockTable(table string) *sync.Mutex {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	lock, ok := r.tableLocks[table]
+	if !ok {
+		lock = &sync.Mutex{}
+		r.tableLocks[table] = lock
+	}
+
+	return lock
+}
+
+func (r *Resolver) cached(table string) (*tableSchema, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	known, ok := r.tables[table]
+	return known, ok
+}
+
+// Ensure makes sure table has at least the given columns. Once a
+// (table, columnSet) pair has been seen and found to match, it's cached and
+// later calls are a no-op. When a column is missing and autoMigrate is off,
+// Ensure returns an error instead of guessing.
+//
+// The whole check-fetch-migrate-cache sequence runs under a per-table lock,
+// so concurrent first-sight calls for the same table (e.g. a burst of
+// inserts racing to create it) serialize instead of issuing concurrent
+// CREATE TABLE / ALTER TABLE statements against each other.
+func (r *Resolver) Ensure(table string, columns []Column) error {
+	lock := r.lockTable(table)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if known, ok := r.cached(table); ok && known.hasAll(columns) {
+		return nil
+	}
+
+	existing, err := r.fetchColumns(table)
+	if err != nil {
+		return err
+	}
+
+	missing := make([]Column, 0, len(columns))
+	for _, col := range columns {
+		if !existing[col.Name] {
+			missing = append(missing, col)
+		}
+	}
+
+	if len(missing) == 0 {
+		r.cache(table, existing)
+		return nil
+	}
+
+	if !r.autoMigrate {
+		return fmt.Errorf("schema: table %q is missing column(s) %s", table, columnNames(missing))
+	}
+
+	if len(existing) == 0 {
+		if err := r.createTable(table, columns); err != nil {
+			r.Invalidate(table)
+			return err
+		}
+	} else if err := r.addColumns(table, missing); err != nil {
+		r.Invalidate(table)
+		return err
+	}
+
+	for _, col := range columns {
+		existing[col.Name] = true
+	}
+	r.cache(table, existing)
+
+	return nil
+}
+
+// Invalidate drops the cached schema for table, forcing the next Ensure to
+// re-check information_schema. Call this after a migration attempt fails so
+// drift is re-checked rather than assumed resolved.
+func (r *Resolver) Invalidate(table string) {
+	r.mutex.Lock()
+	delete(r.tables, table)
+	r.mutex.Unlock()
+}
+
+func (r *Resolver) cache(table string, columns map[string]bool) {
+	r.mutex.Lock()
+	r.tables[table] = &tableSchema{columns: columns}
+	r.mutex.Unlock()
+}
+
+func (r *Resolver) fetchColumns(table string) (map[string]bool, error) {
+	query := fmt.Sprintf(`SELECT column_name FROM information_schema.columns WHERE table_name = %s`, r.dialect.Placeholder(1))
+	rows, err := r.db.Queryx(query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+
+		columns[name] = true
+	}
+
+	return columns, rows.Err()
+}
+
+func (r *Resolver) createTable(table string, columns []Column) error {
+	defs := make([]string, 0, len(columns))
+	primary := ""
+	for _, col := range columns {
+		defs = append(defs, fmt.Sprintf("%s %s", r.dialect.QuoteIdent(col.Name), r.dialect.ColumnType(col.Value)))
+		if col.IsPrimary {
+			primary = col.Name
+		}
+	}
+
+	if primary != "" {
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY (%s)", r.dialect.QuoteIdent(primary)))
+	}
+
+	stmt := fmt.Sprintf("CREATE TABLE %s (%s)", r.dialect.QuoteIdent(table), strings.Join(defs, ","))
+
+	log.WithFields(log.Fields{"table": table}).Info("Auto-migrating: creating table")
+
+	_, err := r.db.Exec(stmt)
+	return err
+}
+
+func (r *Resolver) addColumns(table string, columns []Column) error {
+	for _, col := range columns {
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", r.dialect.QuoteIdent(table), r.dialect.QuoteIdent(col.Name), r.dialect.ColumnType(col.Value))
+
+		log.WithFields(log.Fields{"table": table, "column": col.Name}).Info("Auto-migrating: adding column")
+
+		if _, err := r.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func columnNames(columns []Column) string {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+	}
+
+	return strings.Join(names, ",")
+}
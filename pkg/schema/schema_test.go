@@ -0,0 +1,33 @@
+package schema
+
+import "testing"
+
+func TestTableSchemaHasAll(t *testing.T) {
+	s := &tableSchema{columns: map[string]bool{"id": true, "name": true}}
+
+	tests := []struct {
+		name    string
+		columns []Column
+		want    bool
+	}{
+		{"all present", []Column{{Name: "id"}, {Name: "name"}}, true},
+		{"missing one", []Column{{Name: "id"}, {Name: "email"}}, false},
+		{"empty", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.hasAll(tt.columns); got != tt.want {
+				t.Errorf("hasAll(%v) = %v, want %v", tt.columns, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnNames(t *testing.T) {
+	got := columnNames([]Column{{Name: "id"}, {Name: "email"}})
+	want := "id,email"
+	if got != want {
+		t.Errorf("columnNames() = %s, want %s", got, want)
+	}
+}